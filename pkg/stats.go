@@ -0,0 +1,95 @@
+package sfu
+
+import (
+	"github.com/pion/webrtc/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// iceCandidatesUsed counts ICE connections established per selected candidate-pair type.
+var iceCandidatesUsed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sfu",
+	Name:      "ice_candidates_used",
+	Help:      "Number of ICE connections established per selected candidate-pair type.",
+}, []string{"local_type", "remote_type", "protocol"})
+
+var (
+	bytesSentGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sfu",
+		Name:      "transport_bytes_sent",
+		Help:      "Bytes sent per transport, sampled periodically from pc.GetStats().",
+	}, []string{"transport_id"})
+
+	bytesReceivedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sfu",
+		Name:      "transport_bytes_received",
+		Help:      "Bytes received per transport, sampled periodically from pc.GetStats().",
+	}, []string{"transport_id"})
+)
+
+// CandidatePairStats describes the ICE candidate pair a transport settled on.
+type CandidatePairStats struct {
+	LocalCandidateType  webrtc.ICECandidateType
+	RemoteCandidateType webrtc.ICECandidateType
+	Protocol            webrtc.ICEProtocol
+}
+
+// TrackStats is a per receiver/sender snapshot taken from pc.GetStats().
+type TrackStats struct {
+	TrackID       string
+	PacketsLost   int32
+	Jitter        float64
+	BytesSent     uint64
+	BytesReceived uint64
+}
+
+// TransportStats is a snapshot of a WebRTCTransport's connection stats.
+type TransportStats struct {
+	CandidatePair CandidatePairStats
+	BytesSent     uint64
+	BytesReceived uint64
+	Receivers     []TrackStats
+	Senders       []TrackStats
+}
+
+// recordSelectedCandidatePair increments iceCandidatesUsed for the selected pair.
+func recordSelectedCandidatePair(pair *webrtc.ICECandidatePair) {
+	if pair == nil {
+		return
+	}
+	iceCandidatesUsed.WithLabelValues(
+		pair.Local.Typ.String(),
+		string(pair.Remote.Typ.String()),
+		pair.Local.Protocol.String(),
+	).Inc()
+}
+
+// sampleStats polls pc.GetStats() and returns a TransportStats snapshot.
+func sampleStats(id string, pc *webrtc.PeerConnection) TransportStats {
+	var stats TransportStats
+	report := pc.GetStats()
+	for _, s := range report {
+		switch v := s.(type) {
+		case webrtc.TransportStats:
+			stats.BytesSent = v.BytesSent
+			stats.BytesReceived = v.BytesReceived
+		case webrtc.InboundRTPStreamStats:
+			stats.Receivers = append(stats.Receivers, TrackStats{
+				TrackID:       v.TrackID,
+				PacketsLost:   v.PacketsLost,
+				Jitter:        v.Jitter,
+				BytesReceived: v.BytesReceived,
+			})
+		case webrtc.OutboundRTPStreamStats:
+			stats.Senders = append(stats.Senders, TrackStats{
+				TrackID:   v.TrackID,
+				BytesSent: v.BytesSent,
+			})
+		}
+	}
+
+	bytesSentGauge.WithLabelValues(id).Set(float64(stats.BytesSent))
+	bytesReceivedGauge.WithLabelValues(id).Set(float64(stats.BytesReceived))
+
+	return stats
+}
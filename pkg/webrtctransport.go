@@ -2,6 +2,7 @@ package sfu
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,16 +12,83 @@ import (
 
 	"github.com/bep/debounce"
 	"github.com/lucsky/cuid"
+	"github.com/pion/interceptor"
 	log "github.com/pion/ion-log"
 	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// opsQueueSize bounds the number of pending negotiation operations a
+// transport will buffer before enqueueSDP/enqueueErr block the caller.
+const opsQueueSize = 8
+
+// Default ICE lifecycle timings, mirroring commonly-used SFU values.
+const (
+	defaultICEDisconnectedTimeout = 4 * time.Second
+	defaultICEFailedTimeout       = 6 * time.Second
+	defaultICEKeepAliveInterval   = 2 * time.Second
+	defaultRTCPPLIInterval        = 3 * time.Second
+	defaultStatsUpdateInterval    = 5 * time.Second
+)
+
+// errTransportClosed is returned by queued negotiation operations that were
+// still pending, or never got to run, when the transport was closed.
+var errTransportClosed = errors.New("webrtc transport closed")
+
+// errDataChannelHandlerExists is returned when a label is already registered.
+var errDataChannelHandlerExists = errors.New("data channel handler already registered for this label")
+
+// errDataChannelLabelReserved is returned by RegisterDataChannelHandler for channelLabel.
+var errDataChannelLabelReserved = errors.New("data channel label is reserved for the sfu api channel")
+
+var pliCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "sfu",
+	Subsystem: "router",
+	Name:      "pli_sent",
+	Help:      "Total number of periodic keyframe-request PLI rounds sent to publishers; a round covers every active video receiver and counts once.",
+})
+
 // WebRTCTransportConfig represents configuration options
 type WebRTCTransportConfig struct {
 	configuration webrtc.Configuration
 	setting       webrtc.SettingEngine
 	router        RouterConfig
+	Bandwidth     BandwidthConfig
+
+	// ICEDisconnectedTimeout is how long without network activity before an
+	// ICE Agent is considered disconnected. Defaults to 4s.
+	ICEDisconnectedTimeout time.Duration
+	// ICEFailedTimeout is how long without network activity before an ICE
+	// Agent is considered failed. Defaults to 6s.
+	ICEFailedTimeout time.Duration
+	// ICEKeepAliveInterval is how often ICE keepalives are sent. Defaults to 2s.
+	ICEKeepAliveInterval time.Duration
+	// RTCPPLIInterval is how often a PictureLossIndication is sent for every active video receiver. Defaults to 3s.
+	RTCPPLIInterval time.Duration
+	// StatsUpdateInterval is how often Stats() is refreshed from pc.GetStats(). Defaults to 5s.
+	StatsUpdateInterval time.Duration
+	// Interceptors lets applications register additional pion interceptors
+	// (custom congestion control, packet dumpers, ...) on top of the
+	// defaults that webrtc.RegisterDefaultInterceptors installs on every
+	// transport (NACK and RTCP sender/receiver reports). No TWCC
+	// interceptor is registered by the transport itself.
+	Interceptors []interceptor.Factory
+	// DataChannels are negotiated data channels opened automatically on every transport created with this config.
+	DataChannels map[string]*webrtc.DataChannelInit
+}
+
+// BandwidthConfig controls the bandwidth hints WebRTCTransport inserts into
+// the SDP it generates.
+type BandwidthConfig struct {
+	// MaxBitrate is the default per-track TIAS value, in bits per second,
+	// applied to video m= sections that have no override set via
+	// WebRTCTransport.SetTargetBitrate. Zero disables the default.
+	MaxBitrate uint64
+	// LegacyAS also emits a b=AS line (kbps) alongside b=TIAS for clients
+	// that don't understand TIAS.
+	LegacyAS bool
 }
 
 // WebRTCTransport represents a sfu peer connection
@@ -33,11 +101,27 @@ type WebRTCTransport struct {
 	cancel         context.CancelFunc
 	router         Router
 	session        *Session
+	config         WebRTCTransportConfig
 	senders        map[string][]Sender
 	candidates     []webrtc.ICECandidateInit
 	pendingSenders deque.Deque
+	targetBitrate  map[string]uint64
 	onTrackHandler func(*webrtc.Track, *webrtc.RTPReceiver)
 
+	// stats holds the candidate pair recorded on ICE connect and the latest
+	// traffic figures gathered by statsLoop, guarded by mu.
+	stats TransportStats
+
+	// dcHandlers and channels are keyed by data channel label.
+	dcHandlers map[string]func(*webrtc.DataChannel, []byte)
+	channels   map[string]*webrtc.DataChannel
+
+	// ops serializes SDP negotiation (CreateOffer/CreateAnswer/
+	// SetLocalDescription/SetRemoteDescription) so that concurrent callers
+	// -- signaling and the debounced OnNegotiationNeeded callback alike --
+	// can't interleave offer/answer state or race on pendingSenders.
+	ops chan func()
+
 	subOnce sync.Once
 }
 
@@ -48,7 +132,35 @@ type pendingSender struct {
 
 // NewWebRTCTransport creates a new WebRTCTransport
 func NewWebRTCTransport(ctx context.Context, session *Session, me MediaEngine, cfg WebRTCTransportConfig) (*WebRTCTransport, error) {
-	api := webrtc.NewAPI(webrtc.WithMediaEngine(me.MediaEngine), webrtc.WithSettingEngine(cfg.setting))
+	if cfg.ICEDisconnectedTimeout == 0 {
+		cfg.ICEDisconnectedTimeout = defaultICEDisconnectedTimeout
+	}
+	if cfg.ICEFailedTimeout == 0 {
+		cfg.ICEFailedTimeout = defaultICEFailedTimeout
+	}
+	if cfg.ICEKeepAliveInterval == 0 {
+		cfg.ICEKeepAliveInterval = defaultICEKeepAliveInterval
+	}
+	if cfg.RTCPPLIInterval == 0 {
+		cfg.RTCPPLIInterval = defaultRTCPPLIInterval
+	}
+	if cfg.StatsUpdateInterval == 0 {
+		cfg.StatsUpdateInterval = defaultStatsUpdateInterval
+	}
+	if err := cfg.setting.SetICETimeouts(cfg.ICEDisconnectedTimeout, cfg.ICEFailedTimeout, cfg.ICEKeepAliveInterval); err != nil {
+		log.Errorf("SetICETimeouts error: %v", err)
+	}
+
+	ir := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(me.MediaEngine, ir); err != nil {
+		log.Errorf("RegisterDefaultInterceptors error: %v", err)
+		return nil, err
+	}
+	for _, f := range cfg.Interceptors {
+		ir.Add(f)
+	}
+
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(me.MediaEngine), webrtc.WithSettingEngine(cfg.setting), webrtc.WithInterceptorRegistry(ir))
 	pc, err := api.NewPeerConnection(cfg.configuration)
 
 	if err != nil {
@@ -59,20 +171,35 @@ func NewWebRTCTransport(ctx context.Context, session *Session, me MediaEngine, c
 	ctx, cancel := context.WithCancel(ctx)
 	id := cuid.New()
 	p := &WebRTCTransport{
-		id:      id,
-		ctx:     ctx,
-		cancel:  cancel,
-		pc:      pc,
-		me:      me,
-		session: session,
-		router:  newRouter(pc, id, cfg.router),
-		senders: make(map[string][]Sender),
+		id:            id,
+		ctx:           ctx,
+		cancel:        cancel,
+		pc:            pc,
+		me:            me,
+		session:       session,
+		config:        cfg,
+		router:        newRouter(pc, id, cfg.router),
+		senders:       make(map[string][]Sender),
+		targetBitrate: make(map[string]uint64),
+		dcHandlers:    make(map[string]func(*webrtc.DataChannel, []byte)),
+		channels:      make(map[string]*webrtc.DataChannel),
+		ops:           make(chan func(), opsQueueSize),
 	}
 	p.pendingSenders.SetMinCapacity(2)
 
+	go p.runOps()
+	go p.pliLoop()
+	go p.statsLoop()
+
 	// Add transport to the session
 	session.AddTransport(p)
 
+	for label, dcInit := range cfg.DataChannels {
+		if _, err := p.AddDataChannel(label, dcInit); err != nil {
+			log.Errorf("AddDataChannel %s error: %v", label, err)
+		}
+	}
+
 	pc.OnTrack(func(track *webrtc.Track, receiver *webrtc.RTPReceiver) {
 		log.Debugf("Peer %s got remote track id: %s mediaSSRC: %d rid :%s streamID: %s", p.id, track.ID(), track.SSRC(), track.RID(), track.Label())
 		if rr := p.router.AddReceiver(ctx, track, receiver); rr != nil {
@@ -89,6 +216,7 @@ func NewWebRTCTransport(ctx context.Context, session *Session, me MediaEngine, c
 		if d.Label() == channelLabel {
 			handleAPICommand(p, d)
 		}
+		p.trackDataChannel(d)
 	})
 
 	pc.OnICEConnectionStateChange(func(connectionState webrtc.ICEConnectionState) {
@@ -99,13 +227,33 @@ func NewWebRTCTransport(ctx context.Context, session *Session, me MediaEngine, c
 		default:
 			switch connectionState {
 			case webrtc.ICEConnectionStateConnected:
+				if pair, err := pc.SCTP().Transport().ICETransport().GetSelectedCandidatePair(); err != nil {
+					log.Errorf("GetSelectedCandidatePair error: %v", err)
+				} else if pair != nil {
+					recordSelectedCandidatePair(pair)
+					p.mu.Lock()
+					p.stats.CandidatePair = CandidatePairStats{
+						LocalCandidateType:  pair.Local.Typ,
+						RemoteCandidateType: pair.Remote.Typ,
+						Protocol:            pair.Local.Protocol,
+					}
+					p.mu.Unlock()
+				}
 				p.subOnce.Do(func() {
-					// Subscribe to existing transports
+					// Subscribe to existing transports. AddSender pushes onto
+					// p.pendingSenders, so it's run on p's own ops queue to
+					// serialize it against p's own SetRemoteDescription calls.
 					for _, t := range session.Transports() {
 						if t.ID() == p.id {
 							continue
 						}
-						err := t.GetRouter().AddSender(p, nil)
+						wt, ok := t.(*WebRTCTransport)
+						if !ok {
+							continue
+						}
+						err := p.enqueueErr(func() error {
+							return wt.GetRouter().AddSender(p, nil)
+						})
 						if err != nil {
 							log.Errorf("Subscribing to router err: %v", err)
 							continue
@@ -129,95 +277,240 @@ func NewWebRTCTransport(ctx context.Context, session *Session, me MediaEngine, c
 	return p, nil
 }
 
-// CreateOffer generates the localDescription
-func (p *WebRTCTransport) CreateOffer() (webrtc.SessionDescription, error) {
-	return p.pc.CreateOffer(nil)
+// runOps drains p.ops one closure at a time, guaranteeing that SDP
+// negotiation calls (and the pendingSenders/candidates bookkeeping they
+// drive) never run concurrently with each other. It returns once the
+// transport's context is canceled; any ops still sitting in the channel at
+// that point are simply never run, and their callers are unblocked via
+// ctx.Done() in enqueueSDP/enqueueErr below.
+func (p *WebRTCTransport) runOps() {
+	for {
+		select {
+		case op := <-p.ops:
+			op()
+		case <-p.ctx.Done():
+			return
+		}
+	}
 }
 
-// SetLocalDescription sets the SessionDescription of the remote peer
-func (p *WebRTCTransport) SetLocalDescription(desc webrtc.SessionDescription) error {
-	return p.pc.SetLocalDescription(desc)
+// enqueueSDP runs f on the ops goroutine and waits for its result, returning
+// errTransportClosed if the transport is closed before f could run.
+func (p *WebRTCTransport) enqueueSDP(f func() (webrtc.SessionDescription, error)) (webrtc.SessionDescription, error) {
+	reply := make(chan struct {
+		desc webrtc.SessionDescription
+		err  error
+	}, 1)
+
+	select {
+	case p.ops <- func() {
+		desc, err := f()
+		reply <- struct {
+			desc webrtc.SessionDescription
+			err  error
+		}{desc, err}
+	}:
+	case <-p.ctx.Done():
+		return webrtc.SessionDescription{}, errTransportClosed
+	}
+
+	select {
+	case r := <-reply:
+		return r.desc, r.err
+	case <-p.ctx.Done():
+		return webrtc.SessionDescription{}, errTransportClosed
+	}
 }
 
-// CreateAnswer generates the localDescription
-func (p *WebRTCTransport) CreateAnswer() (webrtc.SessionDescription, error) {
-	offer, err := p.pc.CreateAnswer(nil)
-	if err != nil {
-		log.Errorf("CreateAnswer error: %v", err)
-		return webrtc.SessionDescription{}, err
+// enqueueErr is the error-only counterpart to enqueueSDP.
+func (p *WebRTCTransport) enqueueErr(f func() error) error {
+	reply := make(chan error, 1)
+
+	select {
+	case p.ops <- func() {
+		reply <- f()
+	}:
+	case <-p.ctx.Done():
+		return errTransportClosed
 	}
 
-	return offer, nil
+	select {
+	case err := <-reply:
+		return err
+	case <-p.ctx.Done():
+		return errTransportClosed
+	}
 }
 
-// SetRemoteDescription sets the SessionDescription of the remote peer
-func (p *WebRTCTransport) SetRemoteDescription(desc webrtc.SessionDescription) error {
-	pd, err := desc.Unmarshal()
+// SetTargetBitrate sets the bandwidth hint, in bits per second, that will be
+// signaled via a b=TIAS line on the video m= section carrying trackID the
+// next time an offer or answer is generated. Applications (or the simulcast
+// layer selector) use this to tell a publisher how much bandwidth it may use.
+func (p *WebRTCTransport) SetTargetBitrate(trackID string, bps uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.targetBitrate[trackID] = bps
+}
+
+// setBandwidth rewrites desc.SDP, appending a b=TIAS line (and optionally
+// b=AS, for legacy clients) to every video m= section, using the per-track
+// bitrate set via SetTargetBitrate or falling back to config.Bandwidth.MaxBitrate.
+func (p *WebRTCTransport) setBandwidth(desc *webrtc.SessionDescription) error {
+	parsed, err := desc.Unmarshal()
 	if err != nil {
-		log.Errorf("SetRemoteDescription error: %v", err)
 		return err
 	}
-	err = p.pc.SetRemoteDescription(desc)
+
+	p.mu.RLock()
+	changed := false
+	for _, md := range parsed.MediaDescriptions {
+		if md.MediaName.Media != mediaNameVideo {
+			continue
+		}
+		bps := p.config.Bandwidth.MaxBitrate
+		if msid, ok := md.Attribute(sdp.AttrKeyMsid); ok {
+			v := strings.Split(msid, " ")
+			if tb, ok := p.targetBitrate[v[len(v)-1]]; ok {
+				bps = tb
+			}
+		}
+		if bps == 0 {
+			continue
+		}
+		md.Bandwidth = append(md.Bandwidth, sdp.Bandwidth{Type: "TIAS", Bandwidth: bps})
+		if p.config.Bandwidth.LegacyAS {
+			md.Bandwidth = append(md.Bandwidth, sdp.Bandwidth{Type: "AS", Bandwidth: bps / 1000})
+		}
+		changed = true
+	}
+	p.mu.RUnlock()
+
+	if !changed {
+		return nil
+	}
+
+	raw, err := parsed.Marshal()
 	if err != nil {
-		log.Errorf("SetRemoteDescription error: %v", err)
 		return err
 	}
+	desc.SDP = string(raw)
+	return nil
+}
 
-	if len(p.candidates) > 0 {
-		for _, candidate := range p.candidates {
-			err := p.pc.AddICECandidate(candidate)
-			if err != nil {
-				log.Errorf("Error adding ice candidate %s", err)
+// CreateOffer generates the localDescription
+func (p *WebRTCTransport) CreateOffer() (webrtc.SessionDescription, error) {
+	return p.enqueueSDP(func() (webrtc.SessionDescription, error) {
+		offer, err := p.pc.CreateOffer(nil)
+		if err != nil {
+			return webrtc.SessionDescription{}, err
+		}
+		if err := p.setBandwidth(&offer); err != nil {
+			log.Errorf("setBandwidth error: %v", err)
+		}
+		return offer, nil
+	})
+}
+
+// SetLocalDescription sets the local SessionDescription
+func (p *WebRTCTransport) SetLocalDescription(desc webrtc.SessionDescription) error {
+	return p.enqueueErr(func() error {
+		return p.pc.SetLocalDescription(desc)
+	})
+}
+
+// CreateAnswer generates the localDescription
+func (p *WebRTCTransport) CreateAnswer() (webrtc.SessionDescription, error) {
+	return p.enqueueSDP(func() (webrtc.SessionDescription, error) {
+		answer, err := p.pc.CreateAnswer(nil)
+		if err != nil {
+			log.Errorf("CreateAnswer error: %v", err)
+			return webrtc.SessionDescription{}, err
+		}
+		if err := p.setBandwidth(&answer); err != nil {
+			log.Errorf("setBandwidth error: %v", err)
+		}
+		return answer, nil
+	})
+}
+
+// SetRemoteDescription sets the SessionDescription of the remote peer. Mid
+// matching of pendingSenders against the answer's media descriptions runs on
+// the ops goroutine, atomically with the pc.SetRemoteDescription call, so it
+// can't race a renegotiation triggered immediately afterwards.
+func (p *WebRTCTransport) SetRemoteDescription(desc webrtc.SessionDescription) error {
+	return p.enqueueErr(func() error {
+		pd, err := desc.Unmarshal()
+		if err != nil {
+			log.Errorf("SetRemoteDescription error: %v", err)
+			return err
+		}
+		err = p.pc.SetRemoteDescription(desc)
+		if err != nil {
+			log.Errorf("SetRemoteDescription error: %v", err)
+			return err
+		}
+
+		if len(p.candidates) > 0 {
+			for _, candidate := range p.candidates {
+				err := p.pc.AddICECandidate(candidate)
+				if err != nil {
+					log.Errorf("Error adding ice candidate %s", err)
+				}
 			}
+			p.candidates = nil
 		}
-		p.candidates = nil
-	}
 
-	switch desc.Type {
-	case webrtc.SDPTypeAnswer:
-		if p.pendingSenders.Len() != 0 {
-			for _, md := range pd.MediaDescriptions {
-				if mid, ok := md.Attribute(sdp.AttrKeyMID); ok {
-					for i := 0; i < p.pendingSenders.Len(); i++ {
-						ps := p.pendingSenders.PopFront().(*pendingSender)
-						if ps.transceiver.Mid() == mid {
-							ps.sender.Start()
-						} else {
-							p.pendingSenders.PushBack(ps)
+		switch desc.Type {
+		case webrtc.SDPTypeAnswer:
+			if p.pendingSenders.Len() != 0 {
+				for _, md := range pd.MediaDescriptions {
+					if mid, ok := md.Attribute(sdp.AttrKeyMID); ok {
+						for i := 0; i < p.pendingSenders.Len(); i++ {
+							ps := p.pendingSenders.PopFront().(*pendingSender)
+							if ps.transceiver.Mid() == mid {
+								ps.sender.Start()
+							} else {
+								p.pendingSenders.PushBack(ps)
+							}
 						}
 					}
 				}
 			}
-		}
-	case webrtc.SDPTypeOffer:
-		for _, md := range pd.MediaDescriptions {
-			if md.MediaName.Media != mediaNameAudio && md.MediaName.Media != mediaNameVideo {
-				continue
-			}
-			var (
-				ext int
-				id  string
-			)
-			for _, att := range md.Attributes {
-				if att.Key == sdp.AttrKeyExtMap && strings.HasSuffix(att.Value, sdp.TransportCCURI) {
-					ext, _ = strconv.Atoi(att.Value[:1])
-					if len(id) > 0 {
-						break
-					}
+		case webrtc.SDPTypeOffer:
+			// TODO: the interceptor pipeline registered in NewWebRTCTransport
+			// generates/consumes NACK and RTCP sender/receiver reports, but
+			// nothing yet subscribes the router to an interceptor
+			// bitrate-estimate callback. Until that lands, this manual TWCC
+			// extension-ID parsing stays in place as the router's only way
+			// to interpret incoming TWCC feedback for bandwidth estimation.
+			for _, md := range pd.MediaDescriptions {
+				if md.MediaName.Media != mediaNameAudio && md.MediaName.Media != mediaNameVideo {
+					continue
 				}
-				if att.Key == sdp.AttrKeyMsid {
-					v := strings.Split(att.Value, " ")
-					id = v[len(v)-1]
-					if ext != 0 {
-						break
+				var (
+					ext int
+					id  string
+				)
+				for _, att := range md.Attributes {
+					if att.Key == sdp.AttrKeyExtMap && strings.HasSuffix(att.Value, sdp.TransportCCURI) {
+						ext, _ = strconv.Atoi(att.Value[:1])
+						if len(id) > 0 {
+							break
+						}
+					}
+					if att.Key == sdp.AttrKeyMsid {
+						v := strings.Split(att.Value, " ")
+						id = v[len(v)-1]
+						if ext != 0 {
+							break
+						}
 					}
 				}
+				p.router.AddTWCCExt(id, ext)
 			}
-			p.router.AddTWCCExt(id, ext)
-
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // LocalDescription returns the peer connection LocalDescription
@@ -225,13 +518,16 @@ func (p *WebRTCTransport) LocalDescription() *webrtc.SessionDescription {
 	return p.pc.LocalDescription()
 }
 
-// AddICECandidate to peer connection
+// AddICECandidate to peer connection. Runs on the ops goroutine so it can't
+// race a concurrent SetRemoteDescription's draining of p.candidates.
 func (p *WebRTCTransport) AddICECandidate(candidate webrtc.ICECandidateInit) error {
-	if p.pc.RemoteDescription() != nil {
-		return p.pc.AddICECandidate(candidate)
-	}
-	p.candidates = append(p.candidates, candidate)
-	return nil
+	return p.enqueueErr(func() error {
+		if p.pc.RemoteDescription() != nil {
+			return p.pc.AddICECandidate(candidate)
+		}
+		p.candidates = append(p.candidates, candidate)
+		return nil
+	})
 }
 
 // OnICECandidate handler
@@ -300,6 +596,140 @@ func (p *WebRTCTransport) GetSenders(streamID string) []Sender {
 	return p.senders[streamID]
 }
 
+// pliLoop sends a periodic PictureLossIndication for every active video receiver.
+func (p *WebRTCTransport) pliLoop() {
+	ticker := time.NewTicker(p.config.RTCPPLIInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.router.SendPLI(); err != nil {
+				log.Errorf("periodic PLI error: %v", err)
+				continue
+			}
+			pliCounter.Inc()
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// statsLoop periodically samples pc.GetStats() and caches the result for Stats().
+func (p *WebRTCTransport) statsLoop() {
+	ticker := time.NewTicker(p.config.StatsUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s := sampleStats(p.id, p.pc)
+			p.mu.Lock()
+			p.stats.BytesSent = s.BytesSent
+			p.stats.BytesReceived = s.BytesReceived
+			p.stats.Receivers = s.Receivers
+			p.stats.Senders = s.Senders
+			p.mu.Unlock()
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// Stats returns the candidate pair recorded when ICE last connected together
+// with the traffic figures most recently sampled by statsLoop.
+func (p *WebRTCTransport) Stats() TransportStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stats
+}
+
+// trackDataChannel records d under its label, wires up any registered handler, and untracks it on close.
+func (p *WebRTCTransport) trackDataChannel(d *webrtc.DataChannel) {
+	label := d.Label()
+
+	p.mu.Lock()
+	p.channels[label] = d
+	handler, registered := p.dcHandlers[label]
+	p.mu.Unlock()
+
+	// channelLabel's OnMessage is owned by handleAPICommand; d.OnMessage is
+	// single-slot, so installing another handler here would silently
+	// clobber it.
+	if registered && label != channelLabel {
+		d.OnMessage(func(msg webrtc.DataChannelMessage) {
+			handler(d, msg.Data)
+		})
+	}
+
+	d.OnClose(func() {
+		p.mu.Lock()
+		if p.channels[label] == d {
+			delete(p.channels, label)
+		}
+		p.mu.Unlock()
+	})
+
+	go func() {
+		<-p.ctx.Done()
+		p.mu.Lock()
+		if p.channels[label] == d {
+			delete(p.channels, label)
+		}
+		p.mu.Unlock()
+	}()
+}
+
+// RegisterDataChannelHandler registers handler for messages on the data channel labeled label.
+func (p *WebRTCTransport) RegisterDataChannelHandler(label string, handler func(*webrtc.DataChannel, []byte)) error {
+	if label == channelLabel {
+		return errDataChannelLabelReserved
+	}
+
+	p.mu.Lock()
+	if _, ok := p.dcHandlers[label]; ok {
+		p.mu.Unlock()
+		return errDataChannelHandlerExists
+	}
+	p.dcHandlers[label] = handler
+	d, open := p.channels[label]
+	p.mu.Unlock()
+
+	if open {
+		d.OnMessage(func(msg webrtc.DataChannelMessage) {
+			handler(d, msg.Data)
+		})
+	}
+	return nil
+}
+
+// AddDataChannel declares a negotiated data channel with the given label on this transport.
+func (p *WebRTCTransport) AddDataChannel(label string, cfg *webrtc.DataChannelInit) (*webrtc.DataChannel, error) {
+	d, err := p.pc.CreateDataChannel(label, cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.trackDataChannel(d)
+	return d, nil
+}
+
+// BroadcastDataChannel sends payload on the data channel labeled label for every other transport in the session.
+func (p *WebRTCTransport) BroadcastDataChannel(label string, payload []byte) {
+	for _, t := range p.session.Transports() {
+		wt, ok := t.(*WebRTCTransport)
+		if !ok || wt.id == p.id {
+			continue
+		}
+		wt.mu.RLock()
+		d, open := wt.channels[label]
+		wt.mu.RUnlock()
+		if !open {
+			continue
+		}
+		if err := d.Send(payload); err != nil {
+			log.Errorf("BroadcastDataChannel send on %s err: %v", label, err)
+		}
+	}
+}
+
 // Close peer
 func (p *WebRTCTransport) Close() error {
 	p.session.RemoveTransport(p.id)
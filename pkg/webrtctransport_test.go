@@ -0,0 +1,142 @@
+package sfu
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+func TestSetBandwidthSimulcast(t *testing.T) {
+	raw := "v=0\r\n" +
+		"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"t=0 0\r\n" +
+		"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=msid:stream-1 track-1\r\n" +
+		"m=video 9 UDP/TLS/RTP/SAVPF 97\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=msid:stream-1 track-2\r\n" +
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=msid:stream-1 track-3\r\n"
+
+	p := &WebRTCTransport{
+		config: WebRTCTransportConfig{
+			Bandwidth: BandwidthConfig{MaxBitrate: 500000, LegacyAS: true},
+		},
+		targetBitrate: map[string]uint64{"track-2": 1200000},
+	}
+
+	desc := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: raw}
+	if err := p.setBandwidth(&desc); err != nil {
+		t.Fatalf("setBandwidth returned error: %v", err)
+	}
+
+	parsed, err := desc.Unmarshal()
+	if err != nil {
+		t.Fatalf("failed to parse rewritten SDP: %v", err)
+	}
+
+	if len(parsed.MediaDescriptions) != 3 {
+		t.Fatalf("expected 3 media descriptions, got %d", len(parsed.MediaDescriptions))
+	}
+
+	wantTIAS := map[string]uint64{"track-1": 500000, "track-2": 1200000}
+	for _, md := range parsed.MediaDescriptions {
+		msid, _ := md.Attribute(sdp.AttrKeyMsid)
+		trackID := strings.TrimSpace(strings.Split(msid, " ")[1])
+
+		if md.MediaName.Media != mediaNameVideo {
+			if len(md.Bandwidth) != 0 {
+				t.Errorf("track %s: audio m= section got unexpected bandwidth hints %+v", trackID, md.Bandwidth)
+			}
+			continue
+		}
+
+		want := wantTIAS[trackID]
+		var gotTIAS, gotAS uint64
+		for _, bw := range md.Bandwidth {
+			switch bw.Type {
+			case "TIAS":
+				gotTIAS = bw.Bandwidth
+			case "AS":
+				gotAS = bw.Bandwidth
+			}
+		}
+		if gotTIAS != want {
+			t.Errorf("track %s: got TIAS %d, want %d", trackID, gotTIAS, want)
+		}
+		if gotAS != want/1000 {
+			t.Errorf("track %s: got AS %d, want %d", trackID, gotAS, want/1000)
+		}
+	}
+}
+
+func TestSetBandwidthNoop(t *testing.T) {
+	raw := "v=0\r\n" +
+		"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"t=0 0\r\n" +
+		"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=msid:stream-1 track-1\r\n"
+
+	p := &WebRTCTransport{targetBitrate: map[string]uint64{}}
+
+	desc := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: raw}
+	if err := p.setBandwidth(&desc); err != nil {
+		t.Fatalf("setBandwidth returned error: %v", err)
+	}
+	if desc.SDP != raw {
+		t.Fatalf("expected SDP to be left untouched when no bitrate is configured, got %q", desc.SDP)
+	}
+}
+
+// TestOpsQueueSerializesConcurrentCalls exercises the guarantee the subOnce
+// block's AddSender calls rely on: enqueueErr and enqueueSDP both run their closures
+// on the same p.ops goroutine, so a concurrent AddSender (pendingSenders
+// mutation) and SetRemoteDescription (pendingSenders drain) can never
+// interleave even when called from different goroutines.
+func TestOpsQueueSerializesConcurrentCalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := &WebRTCTransport{ctx: ctx, ops: make(chan func())}
+	go p.runOps()
+
+	var running, overlapped int32
+	const n = 200
+	work := func() error {
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		defer atomic.StoreInt32(&running, 0)
+		return nil
+	}
+
+	done := make(chan struct{}, 2*n)
+	for i := 0; i < n; i++ {
+		go func() {
+			p.enqueueErr(work)
+			done <- struct{}{}
+		}()
+		go func() {
+			p.enqueueSDP(func() (webrtc.SessionDescription, error) {
+				return webrtc.SessionDescription{}, work()
+			})
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 2*n; i++ {
+		<-done
+	}
+
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Fatal("enqueueErr and enqueueSDP closures ran concurrently on p.ops")
+	}
+}